@@ -600,6 +600,7 @@ func loadDefaultSettingsFor(v *viper.Viper) error {
 	v.SetDefault("canonifyURLs", false)
 	v.SetDefault("relativeURLs", false)
 	v.SetDefault("removePathAccents", false)
+	v.SetDefault("normalizeFilenames", false)
 	v.SetDefault("titleCaseStyle", "AP")
 	v.SetDefault("taxonomies", map[string]string{"tag": "tags", "category": "categories"})
 	v.SetDefault("permalinks", make(map[string]string))
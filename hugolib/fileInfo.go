@@ -42,6 +42,10 @@ type fileInfo struct {
 
 	overriddenLang string
 
+	// The content relative path as it is on disk. This is what to use when
+	// reading from the content filesystem, as Path may be normalized.
+	fsPath string
+
 	// Set if the content language for this file is disabled.
 	disabled bool
 }
@@ -86,6 +90,7 @@ func newFileInfo(sp *source.SourceSpec, baseDir, filename string, fi pathLangFil
 		bundleTp:     tp,
 		ReadableFile: baseFi,
 		basePather:   fi,
+		fsPath:       fi.Path(),
 	}
 
 	lang := f.Lang()
@@ -28,3 +28,33 @@ func TestFileInfo(t *testing.T) {
 		assert.NoError(err)
 	})
 }
+
+func TestClassifyBundledFile(t *testing.T) {
+	assert := require.New(t)
+
+	for _, test := range []struct {
+		name      string
+		tp        bundleDirType
+		isContent bool
+	}{
+		{"index.md", bundleLeaf, true},
+		{"index.nn.md", bundleLeaf, true},
+		{"_index.md", bundleBranch, true},
+		{"_index.nn.md", bundleBranch, true},
+		{"page.md", bundleNot, true},
+		{"index.png", bundleNot, false},
+		{"logo.png", bundleNot, false},
+		{"page.html", bundleNot, true},
+		{"index.html", bundleLeaf, true},
+		{"page.adoc", bundleNot, true},
+		{"page.org", bundleNot, true},
+		{"page.rst", bundleNot, true},
+		{"page.pandoc", bundleNot, true},
+		{"page.xyz", bundleNot, false},
+		{"style.css", bundleNot, false},
+	} {
+		tp, isContent := classifyBundledFile(test.name)
+		assert.Equal(test.tp, tp, test.name)
+		assert.Equal(test.isContent, isContent, test.name)
+	}
+}
@@ -973,7 +973,7 @@ func (m *contentChangeMap) add(filename string, tp bundleDirType) {
 // Track the addition of bundle dirs.
 func (m *contentChangeMap) handleBundles(b *bundleDirs) {
 	for _, bd := range b.bundles {
-		m.add(bd.fi.Path(), bd.tp)
+		m.add(bd.fi.fsPath, bd.tp)
 	}
 }
 
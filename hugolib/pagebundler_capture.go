@@ -15,7 +15,6 @@ package hugolib
 
 import (
 	"errors"
-	"fmt"
 	"os"
 	"path"
 	"path/filepath"
@@ -36,17 +35,31 @@ import (
 	"github.com/gohugoio/hugo/helpers"
 
 	"golang.org/x/sync/errgroup"
+	"golang.org/x/text/unicode/norm"
 
 	"github.com/gohugoio/hugo/source"
 )
 
-var errSkipCyclicDir = errors.New("skip potential cyclic dir")
+var (
+	errSkipCyclicDir = errors.New("skip potential cyclic dir")
+
+	// Returned when a symbolic link to a dir is found while dirs are handled
+	// concurrently.
+	errDeferSymlinkedDir = errors.New("defer dir with symbolic link")
+)
 
 type capturer struct {
 	// To prevent symbolic link cycles: Visit same folder only once.
 	seen   map[string]bool
 	seenMu sync.Mutex
 
+	// The content root dirs that have been resolved and added to seen.
+	seenRoots map[string]bool
+
+	// The dirs already checked for name collisions. A dir may be read more
+	// than once, e.g. when it is deferred or in partial builds.
+	collisionsChecked map[string]bool
+
 	handler captureResultHandler
 
 	sourceSpec *source.SourceSpec
@@ -62,6 +75,15 @@ type capturer struct {
 
 	// Semaphore used to throttle the concurrent sub directory handling.
 	sem chan bool
+
+	// When set, dirs are handled one by one, and symbolic links to dirs are
+	// resolved when found.
+	serial bool
+
+	// Dirs with symbolic links to dirs in them. These are handled serially
+	// after the other dirs.
+	deferred   []string
+	deferredMu sync.Mutex
 }
 
 func newCapturer(
@@ -73,25 +95,19 @@ func newCapturer(
 
 	numWorkers := config.GetNumWorkerMultiplier()
 
-	// TODO(bep) the "index" vs "_index" check/strings should be moved in one place.
 	isBundleHeader := func(filename string) bool {
-		base := filepath.Base(filename)
-		name := helpers.Filename(base)
-		return IsContentFile(base) && (name == "index" || name == "_index")
+		tp, _ := classifyBundledFile(filepath.Base(filename))
+		return tp > bundleNot
 	}
 
 	// Make sure that any bundle header files are processed before the others. This makes
 	// sure that any bundle head is processed before its resources.
-	sort.Slice(filenames, func(i, j int) bool {
+	sort.SliceStable(filenames, func(i, j int) bool {
 		a, b := filenames[i], filenames[j]
 		ac, bc := isBundleHeader(a), isBundleHeader(b)
 
-		if ac {
-			return true
-		}
-
-		if bc {
-			return false
+		if ac != bc {
+			return ac
 		}
 
 		return a < b
@@ -105,8 +121,11 @@ func newCapturer(
 		logger:         logger,
 		contentChanges: contentChanges,
 		seen:           make(map[string]bool),
+		seenRoots:      make(map[string]bool),
 		filenames:      filenames}
 
+	c.collisionsChecked = make(map[string]bool)
+
 	return c
 }
 
@@ -196,6 +215,8 @@ func (c *capturer) capturePartial(filenames ...string) error {
 
 func (c *capturer) capture() error {
 	if len(c.filenames) > 0 {
+		// Partial builds only handle a few dirs.
+		c.serial = true
 		return c.capturePartial(c.filenames...)
 	}
 
@@ -204,25 +225,66 @@ func (c *capturer) capture() error {
 		return err
 	}
 
+	return c.handleDeferred()
+}
+
+// handleDeferred handles the dirs with symbolic links to dirs in them. Which
+// of several links to the same dir gets processed depends on the order they
+// are found in, so this is done one by one in sorted order.
+func (c *capturer) handleDeferred() error {
+	c.serial = true
+
+	sort.Strings(c.deferred)
+
+	for _, dirname := range c.deferred {
+		if err := c.handleDir(dirname); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-func (c *capturer) handleNestedDir(dirname string) error {
-	select {
-	case c.sem <- true:
-		var g errgroup.Group
-
-		g.Go(func() error {
-			defer func() {
-				<-c.sem
-			}()
-			return c.handleDir(dirname)
-		})
-		return g.Wait()
-	default:
-		// For deeply nested file trees, waiting for a semaphore wil deadlock.
-		return c.handleDir(dirname)
+func (c *capturer) deferDir(dirname string) {
+	c.deferredMu.Lock()
+	c.deferred = append(c.deferred, dirname)
+	c.deferredMu.Unlock()
+}
+
+// handleNestedDirs handles the given sibling dirs concurrently, bounded by the
+// number of workers.
+func (c *capturer) handleNestedDirs(dirnames ...string) error {
+	if c.serial {
+		for _, dirname := range dirnames {
+			if err := c.handleDir(dirname); err != nil {
+				return err
+			}
+		}
+		return nil
 	}
+
+	var g errgroup.Group
+
+	for _, dirname := range dirnames {
+		dirname := dirname
+		select {
+		case c.sem <- true:
+			g.Go(func() error {
+				defer func() {
+					<-c.sem
+				}()
+				return c.handleDir(dirname)
+			})
+		default:
+			// For deeply nested file trees, waiting for a semaphore wil deadlock.
+			if err := c.handleDir(dirname); err != nil {
+				g.Wait()
+				return err
+			}
+		}
+	}
+
+	return g.Wait()
 }
 
 // This handles a bundle branch and its resources only. This is used
@@ -242,13 +304,23 @@ func (c *capturer) handleBranchDir(dirname string) error {
 	for _, fi := range files {
 		if !fi.IsDir() {
 			tp, _ := classifyBundledFile(fi.RealName())
-			if dirType == bundleNot {
-				dirType = tp
+			if tp == bundleNot {
+				continue
+			}
+
+			if _, active := c.newFileInfo(fi, tp); !active {
+				// Bundle headers in disabled languages do not count.
+				continue
 			}
 
-			if dirType == bundleLeaf {
+			if tp == bundleLeaf {
+				// Leaf bundles take precedence.
 				return c.handleDir(dirname)
 			}
+
+			if dirType == bundleNot {
+				dirType = tp
+			}
 		}
 	}
 
@@ -298,6 +370,10 @@ func (c *capturer) handleBranchDir(dirname string) error {
 func (c *capturer) handleDir(dirname string) error {
 
 	files, err := c.readDir(dirname)
+	if err == errDeferSymlinkedDir {
+		c.deferDir(dirname)
+		return nil
+	}
 	if err != nil {
 		return err
 	}
@@ -333,15 +409,36 @@ func (c *capturer) handleDir(dirname string) error {
 	- If this is a section with no image etc. or similar, we can just handle it
 	as it was a single content file.
 	*/
-	var hasNonContent, isBranch bool
+	var (
+		hasNonContent, isBranch, isLeaf bool
+		leafHeader, branchHeader        string
+	)
 
 	for i, fi := range files {
 		if !fi.IsDir() {
 			tp, isContent := classifyBundledFile(fi.RealName())
 
 			fileBundleTypes[i] = tp
-			if !isBranch {
-				isBranch = tp == bundleBranch
+
+			if tp != bundleNot {
+				if _, active := c.newFileInfo(fi, tp); !active {
+					// Bundle headers in disabled languages do not count, e.g.
+					// index.nn.md next to _index.md does not make this a leaf bundle.
+					tp = bundleNot
+				}
+			}
+
+			switch tp {
+			case bundleLeaf:
+				if !isLeaf {
+					isLeaf = true
+					leafHeader = fi.Filename()
+				}
+			case bundleBranch:
+				if !isBranch {
+					isBranch = true
+					branchHeader = fi.Filename()
+				}
 			}
 
 			if isContent {
@@ -353,6 +450,19 @@ func (c *capturer) handleDir(dirname string) error {
 		}
 	}
 
+	// Leaf bundles take precedence. The branch bundle headers will be
+	// handled as regular content files in the leaf bundle.
+	leafAndBranch := isLeaf && isBranch
+
+	if leafAndBranch {
+		isBranch = false
+		for i, tp := range fileBundleTypes {
+			if tp == bundleBranch {
+				fileBundleTypes[i] = bundleNot
+			}
+		}
+	}
+
 	if isBranch && !hasNonContent {
 		// This is a section or similar with no need for any bundle handling.
 		state = dirStateSinglesOnly
@@ -362,6 +472,14 @@ func (c *capturer) handleDir(dirname string) error {
 		return c.handleNonBundle(dirname, files, state == dirStateSinglesOnly)
 	}
 
+	// The active bundle headers decide the bundle type. If there are none,
+	// the first header in a disabled language does.
+	if isLeaf {
+		bundleType = bundleLeaf
+	} else if isBranch {
+		bundleType = bundleBranch
+	}
+
 	var fileInfos = make([]*fileInfo, 0, len(files))
 
 	for i, fi := range files {
@@ -388,15 +506,16 @@ func (c *capturer) handleDir(dirname string) error {
 		fileInfos = append(fileInfos, f)
 	}
 
-	var todo []*fileInfo
+	var (
+		todo   []*fileInfo
+		nested []string
+	)
 
 	if bundleType != bundleLeaf {
 		for _, fi := range fileInfos {
 			if fi.FileInfo().IsDir() {
 				// Handle potential nested bundles.
-				if err := c.handleNestedDir(fi.Path()); err != nil {
-					return err
-				}
+				nested = append(nested, fi.fsPath)
 			} else if bundleType == bundleNot || (!fi.isOwner() && fi.isContentFile()) {
 				// Not in a bundle.
 				c.copyOrHandleSingle(fi)
@@ -409,15 +528,30 @@ func (c *capturer) handleDir(dirname string) error {
 		todo = fileInfos
 	}
 
+	if err := c.handleNestedDirs(nested...); err != nil {
+		return err
+	}
+
 	if len(todo) == 0 {
 		return nil
 	}
 
-	dirs, err := c.createBundleDirs(todo, bundleType)
+	dirs, err := c.createBundleDirs(dirname, todo, bundleType)
+	if err == errDeferSymlinkedDir {
+		// A leaf bundle with a symbolic link to a dir in it. Nothing has been
+		// sent to the handler or logged for it yet.
+		c.deferDir(dirname)
+		return nil
+	}
 	if err != nil {
 		return err
 	}
 
+	if leafAndBranch {
+		// Logged here and not above, as this dir may have been deferred.
+		c.logger.WARN.Printf("Content dir %q has both a leaf bundle header (%q) and a branch bundle header (%q); it will be handled as a leaf bundle.", filepath.Dir(leafHeader), filepath.Base(leafHeader), filepath.Base(branchHeader))
+	}
+
 	// Send the bundle to the next step in the processor chain.
 	c.handler.handleBundles(dirs)
 
@@ -429,11 +563,11 @@ func (c *capturer) handleNonBundle(
 	fileInfos pathLangFileFis,
 	singlesOnly bool) error {
 
+	var nested []string
+
 	for _, fi := range fileInfos {
 		if fi.IsDir() {
-			if err := c.handleNestedDir(fi.Filename()); err != nil {
-				return err
-			}
+			nested = append(nested, fi.Filename())
 		} else {
 			if singlesOnly {
 				f, active := c.newFileInfo(fi, bundleNot)
@@ -447,7 +581,7 @@ func (c *capturer) handleNonBundle(
 		}
 	}
 
-	return nil
+	return c.handleNestedDirs(nested...)
 }
 
 func (c *capturer) copyOrHandleSingle(fi *fileInfo) {
@@ -459,7 +593,7 @@ func (c *capturer) copyOrHandleSingle(fi *fileInfo) {
 	}
 }
 
-func (c *capturer) createBundleDirs(fileInfos []*fileInfo, bundleType bundleDirType) (*bundleDirs, error) {
+func (c *capturer) createBundleDirs(dirname string, fileInfos []*fileInfo, bundleType bundleDirType) (*bundleDirs, error) {
 	dirs := newBundleDirs(bundleType, c)
 
 	for _, fi := range fileInfos {
@@ -480,7 +614,7 @@ func (c *capturer) createBundleDirs(fileInfos []*fileInfo, bundleType bundleDirT
 					fileInfos = append(fileInfos, fis...)
 				}
 			}
-			err := c.collectFiles(fi.Path(), collector)
+			err := c.collectFiles(fi.fsPath, collector)
 			if err != nil {
 				return nil, err
 			}
@@ -494,6 +628,25 @@ func (c *capturer) createBundleDirs(fileInfos []*fileInfo, bundleType bundleDirT
 		}
 	}
 
+	if len(dirs.bundles) == 0 {
+		// There is no active bundle header in any language, e.g. when it
+		// only exists in a disabled language.
+		var orphans []string
+		for _, fi := range fileInfos {
+			if !fi.FileInfo().IsDir() {
+				orphans = append(orphans, fi.Filename())
+			}
+		}
+		if len(orphans) > 0 {
+			c.logger.WARN.Printf("Content dir %q has no bundle header in any enabled language; skipped the bundled files %q.", filepath.Join(fileInfos[0].BaseDir(), dirname), orphans)
+		}
+		return dirs, nil
+	}
+
+	var files []*fileInfo
+
+	// Add the content files first. This may clone bundles for languages
+	// without a bundle header, and these clones need all the shared files.
 	for _, fi := range fileInfos {
 		if fi.FileInfo().IsDir() || fi.isOwner() {
 			continue
@@ -504,10 +657,14 @@ func (c *capturer) createBundleDirs(fileInfos []*fileInfo, bundleType bundleDirT
 				dirs.addBundleContentFile(fi)
 			}
 		} else {
-			dirs.addBundleFiles(fi)
+			files = append(files, fi)
 		}
 	}
 
+	for _, fi := range files {
+		dirs.addBundleFiles(fi)
+	}
+
 	return dirs, nil
 }
 
@@ -571,9 +728,56 @@ func (c *capturer) readDir(dirname string) (pathLangFileFis, error) {
 		}
 	}
 
+	c.checkNameCollisions(dirname, pfis)
+
 	return pfis, nil
 }
 
+// checkNameCollisions warns about dir entries with names that only differ
+// in Unicode normalization form (e.g. NFD from macOS and NFC) or in case.
+// Only one of them can exist on some file systems, and lookups by name may
+// find the wrong one. Every dir is checked once per capture.
+func (c *capturer) checkNameCollisions(dirname string, fis pathLangFileFis) {
+	if len(fis) < 2 {
+		return
+	}
+
+	c.seenMu.Lock()
+	checked := c.collisionsChecked[dirname]
+	c.collisionsChecked[dirname] = true
+	c.seenMu.Unlock()
+
+	if checked {
+		return
+	}
+
+	var (
+		normalized = make(map[string]string, len(fis))
+		lowered    = make(map[string]string, len(fis))
+	)
+
+	for _, fi := range fis {
+		filename := fi.Filename()
+		key := norm.NFC.String(filepath.Base(filename))
+		if other, found := normalized[key]; found {
+			c.logger.WARN.Printf("Content files %q and %q only differ in Unicode normalization form; only one of them can exist on macOS.", other, filename)
+			continue
+		}
+		normalized[key] = filename
+
+		key = strings.ToLower(key)
+		if other, found := lowered[key]; found {
+			if c.sourceSpec.DisablePathToLower {
+				c.logger.WARN.Printf("Content files %q and %q only differ in case; only one of them can exist on a case-insensitive file system.", other, filename)
+			} else {
+				c.logger.WARN.Printf("Content files %q and %q only differ in case; only one of them can exist on a case-insensitive file system, and they will be published to the same path.", other, filename)
+			}
+			continue
+		}
+		lowered[key] = filename
+	}
+}
+
 func (c *capturer) newFileInfo(fi pathLangFileFi, tp bundleDirType) (*fileInfo, bool) {
 	f := newFileInfo(c.sourceSpec, "", "", fi, tp)
 	return f, !f.disabled
@@ -630,6 +834,8 @@ func (b *bundleDirs) addBundleContentFile(fi *fileInfo) {
 		// Every bundled content file needs a bundle header.
 		// If one does not exist in its language, we pick the default
 		// language version, or a random one if that doesn't exist, either.
+		// createBundleDirs only adds content files when there is at least
+		// one active bundle header, so ldir is always set.
 		tl := b.c.sourceSpec.DefaultContentLanguage
 		ldir, found := b.bundles[tl]
 		if !found {
@@ -640,10 +846,6 @@ func (b *bundleDirs) addBundleContentFile(fi *fileInfo) {
 			}
 		}
 
-		if ldir == nil {
-			panic(fmt.Sprintf("bundle not found for file %q", fi.Filename()))
-		}
-
 		dir = ldir.clone()
 		dir.fi.overriddenLang = fi.Lang()
 		b.bundles[fi.Lang()] = dir
@@ -676,12 +878,25 @@ func (c *capturer) isSeen(dirname string) bool {
 	defer c.seenMu.Unlock()
 	seen := c.seen[dirname]
 	c.seen[dirname] = true
-	if seen {
-		c.logger.INFO.Printf("Content dir %q already processed; skipped to avoid infinite recursion.", dirname)
-		return true
+	return seen
+}
+
+// markRootSeen marks the real path of the given content root dir as seen, so
+// symbolic links pointing back to the root (which may itself be a symbolic link)
+// are detected as cycles.
+func (c *capturer) markRootSeen(baseDir string) {
+	c.seenMu.Lock()
+	defer c.seenMu.Unlock()
+	if c.seenRoots[baseDir] {
+		return
+	}
+	c.seenRoots[baseDir] = true
 
+	realDir, err := filepath.EvalSymlinks(baseDir)
+	if err != nil {
+		return
 	}
-	return false
+	c.seen[realDir] = true
 }
 
 func (c *capturer) resolveRealPath(path string) (pathLangFileFi, error) {
@@ -718,12 +933,21 @@ func (c *capturer) resolveRealPathIn(fileInfo pathLangFileFi) error {
 
 		realPath = link
 
+		if sfi.IsDir() && !c.serial {
+			return errDeferSymlinkedDir
+		}
+
+		if sfi.IsDir() {
+			c.markRootSeen(basePath)
+		}
+
 		if realPath != path && sfi.IsDir() && c.isSeen(realPath) {
 			// Avoid cyclic symlinks.
 			// Note that this may prevent some uses that isn't cyclic and also
 			// potential useful, but this implementation is both robust and simple:
 			// We stop at the first directory that we have seen before, e.g.
 			// /content/blog will only be processed once.
+			c.logger.WARN.Printf("Content dir %q is a symbolic link to %q, which is already processed; skipped to avoid infinite recursion.", path, realPath)
 			return errSkipCyclicDir
 		}
 
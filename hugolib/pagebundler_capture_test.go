@@ -14,7 +14,9 @@
 package hugolib
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
@@ -28,8 +30,13 @@ import (
 	"testing"
 
 	"github.com/gohugoio/hugo/helpers"
+	"github.com/gohugoio/hugo/hugofs"
 	"github.com/gohugoio/hugo/source"
+	"github.com/spf13/afero"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/require"
+
+	jww "github.com/spf13/jwalterweatherman"
 )
 
 type storeFilenames struct {
@@ -80,6 +87,13 @@ func (s *storeFilenames) sortedStr() string {
 		"\nC:\n" + strings.Join(s.copyNames, "\n") + "\n"
 }
 
+// newWarningBufferLogger returns a logger that writes warnings and errors to
+// the returned buffer.
+func newWarningBufferLogger() (*loggers.Logger, *bytes.Buffer) {
+	var logBuf bytes.Buffer
+	return loggers.NewLogger(jww.LevelWarn, jww.LevelError, &logBuf, ioutil.Discard, false), &logBuf
+}
+
 func TestPageBundlerCaptureSymlinks(t *testing.T) {
 	if runtime.GOOS == "windows" && os.Getenv("CI") == "" {
 		t.Skip("Skip TestPageBundlerCaptureSymlinks as os.Symlink needs administrator rights on Windows")
@@ -102,8 +116,6 @@ F:
 /base/a/regular.md
 /base/symbolic1/s1.md
 /base/symbolic1/s2.md
-/base/symbolic3/circus/a/page_s.md
-/base/symbolic3/circus/a/regular.md
 D:
 __bundle/en/base/symbolic2/a1/index.md/resources/en/base/symbolic2/a1/logo.png|en/base/symbolic2/a1/page.md
 C:
@@ -121,6 +133,69 @@ C:
 	}
 }
 
+func TestPageBundlerCaptureSymlinkedRoot(t *testing.T) {
+	if runtime.GOOS == "windows" && os.Getenv("CI") == "" {
+		t.Skip("Skip TestPageBundlerCaptureSymlinkedRoot as os.Symlink needs administrator rights on Windows")
+	}
+
+	assert := require.New(t)
+
+	cfg := viper.New()
+	fs := hugofs.NewFrom(hugofs.Os, cfg)
+	assert.NoError(loadDefaultSettingsFor(cfg))
+
+	workDir, clean, err := createTempDir("hugosymroot")
+	assert.NoError(err)
+	defer clean()
+
+	cfg.Set("workingDir", workDir)
+	cfg.Set("contentDir", "content")
+	assert.NoError(loadLanguageSettings(cfg, nil))
+
+	realContentDir := filepath.Join(workDir, "realcontent")
+	assert.NoError(fs.Source.MkdirAll(filepath.Join(realContentDir, "sect", "bundle"), 0777))
+	writeSource(t, fs, filepath.Join(realContentDir, "sect", "page.md"), "content")
+	writeSource(t, fs, filepath.Join(realContentDir, "sect", "bundle", "index.md"), "content")
+	writeSource(t, fs, filepath.Join(realContentDir, "sect", "bundle", "logo.png"), "image")
+
+	// The content root is a symbolic link.
+	assert.NoError(os.Symlink(realContentDir, filepath.Join(workDir, "content")))
+	// A symbolic link back to the content root.
+	assert.NoError(os.Symlink(filepath.FromSlash(".."), filepath.Join(realContentDir, "sect", "root")))
+
+	ps, err := helpers.NewPathSpec(fs, cfg)
+	assert.NoError(err)
+	sourceSpec := source.NewSourceSpec(ps, ps.BaseFs.Content.Fs)
+
+	logger, logBuf := newWarningBufferLogger()
+
+	fileStore := &storeFilenames{}
+	c := newCapturer(logger, sourceSpec, fileStore, nil)
+
+	assert.NoError(c.capture())
+
+	logged := logBuf.String()
+	assert.Equal(1, strings.Count(logged, "skipped to avoid infinite recursion"))
+	assert.Contains(logged, fmt.Sprintf("%q is a symbolic link to %q", filepath.Join(workDir, "content", "sect", "root"), realContentDir))
+
+	expected := `
+F:
+/content/sect/page.md
+D:
+__bundle/en/content/sect/bundle/index.md/resources/en/content/sect/bundle/logo.png
+C:
+
+`
+
+	got := strings.Replace(fileStore.sortedStr(), filepath.ToSlash(workDir), "", -1)
+
+	if expected != got {
+		diff := helpers.DiffStringSlices(strings.Fields(expected), strings.Fields(got))
+		t.Log(got)
+		t.Fatalf("Failed:\n%s", diff)
+	}
+}
+
 func TestPageBundlerCaptureBasic(t *testing.T) {
 	t.Parallel()
 
@@ -166,6 +241,45 @@ C:
 	}
 }
 
+// A read-only filesystem without Lstat support, like one backed by a git tree
+// at a given revision, must capture the same as the regular content filesystem.
+func TestPageBundlerCaptureReadOnlyNoLstat(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+	fs, cfg := newTestBundleSources(t)
+	assert.NoError(loadDefaultSettingsFor(cfg))
+	assert.NoError(loadLanguageSettings(cfg, nil))
+	ps, err := helpers.NewPathSpec(fs, cfg)
+	assert.NoError(err)
+
+	capture := func(contentFs afero.Fs) string {
+		fileStore := &storeFilenames{}
+		c := newCapturer(loggers.NewErrorLogger(), source.NewSourceSpec(ps, contentFs), fileStore, nil)
+		assert.NoError(c.capture())
+		return fileStore.sortedStr()
+	}
+
+	readOnlyFs := afero.NewReadOnlyFs(fs.Source)
+	noLstatFs := hugofs.NewNoLstatFs(readOnlyFs)
+	_, lstatCalled, err := noLstatFs.(afero.Lstater).LstatIfPossible(filepath.FromSlash("/work/base/_index.md"))
+	assert.NoError(err)
+	assert.False(lstatCalled)
+	assert.Error(afero.WriteFile(noLstatFs, filepath.FromSlash("/work/base/new.md"), []byte("content"), 0755))
+
+	contentFs := hugofs.NewLanguageFs("en", map[string]bool{"en": true}, afero.NewBasePathFs(noLstatFs, filepath.FromSlash("/work/base")))
+
+	expected := capture(ps.BaseFs.Content.Fs)
+	got := capture(contentFs)
+
+	assert.Contains(expected, "__bundle/en/work/base/b/my-bundle/index.md")
+	if expected != got {
+		diff := helpers.DiffStringSlices(strings.Fields(expected), strings.Fields(got))
+		t.Log(got)
+		t.Fatalf("Failed:\n%s", diff)
+	}
+}
+
 func TestPageBundlerCaptureMultilingual(t *testing.T) {
 	t.Parallel()
 
@@ -220,6 +334,513 @@ C:
 
 }
 
+func TestPageBundlerCaptureLeafAndBranchHeader(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+	cfg, fs := newTestCfg()
+	cfg.Set("workingDir", "/work")
+	cfg.Set("contentDir", "base")
+	cfg.Set("defaultContentLanguage", "en")
+	cfg.Set("languages", map[string]interface{}{
+		"en": map[string]interface{}{
+			"weight": 1,
+		},
+		"nn": map[string]interface{}{
+			"weight": 2,
+		},
+	})
+	cfg.Set("disableLanguages", []string{"nn"})
+	assert.NoError(loadDefaultSettingsFor(cfg))
+	assert.NoError(loadLanguageSettings(cfg, nil))
+
+	writeSource(t, fs, filepath.FromSlash("/work/base/b/index.md"), "content")
+	writeSource(t, fs, filepath.FromSlash("/work/base/b/_index.md"), "content")
+	writeSource(t, fs, filepath.FromSlash("/work/base/b/logo.png"), "image")
+
+	// Content files only.
+	writeSource(t, fs, filepath.FromSlash("/work/base/c/_index.md"), "content")
+	writeSource(t, fs, filepath.FromSlash("/work/base/c/index.md"), "content")
+
+	// The leaf bundle header is in a disabled language.
+	writeSource(t, fs, filepath.FromSlash("/work/base/s/_index.md"), "content")
+	writeSource(t, fs, filepath.FromSlash("/work/base/s/index.nn.md"), "content")
+	writeSource(t, fs, filepath.FromSlash("/work/base/s/logo.png"), "image")
+
+	ps, err := helpers.NewPathSpec(fs, cfg)
+	assert.NoError(err)
+	sourceSpec := source.NewSourceSpec(ps, ps.BaseFs.Content.Fs)
+
+	logger, logBuf := newWarningBufferLogger()
+
+	fileStore := &storeFilenames{}
+	c := newCapturer(logger, sourceSpec, fileStore, nil)
+
+	assert.NoError(c.capture())
+
+	expected := `
+F:
+
+D:
+__bundle/en/work/base/b/index.md/resources/en/work/base/b/_index.md|en/work/base/b/logo.png
+__bundle/en/work/base/c/index.md/resources/en/work/base/c/_index.md
+__bundle/en/work/base/s/_index.md/resources/en/work/base/s/logo.png
+C:
+
+`
+
+	got := fileStore.sortedStr()
+
+	if expected != got {
+		diff := helpers.DiffStringSlices(strings.Fields(expected), strings.Fields(got))
+		t.Log(got)
+		t.Fatalf("Failed:\n%s", diff)
+	}
+
+	logged := logBuf.String()
+	assert.Equal(2, strings.Count(logged, "has both a leaf bundle header"))
+	assert.Contains(logged, filepath.FromSlash(`"/work/base/b" has both a leaf bundle header ("index.md") and a branch bundle header ("_index.md")`))
+	assert.NotContains(logged, filepath.FromSlash("/work/base/s"))
+
+	// Partial rebuild of the branch bundle.
+	fileStore = &storeFilenames{}
+	c = newCapturer(logger, sourceSpec, fileStore, nil)
+
+	assert.NoError(c.handleBranchDir(filepath.FromSlash("/s")))
+
+	expected = `
+F:
+
+D:
+__bundle/en/work/base/s/_index.md/resources/en/work/base/s/logo.png
+C:
+
+`
+
+	got = fileStore.sortedStr()
+
+	if expected != got {
+		diff := helpers.DiffStringSlices(strings.Fields(expected), strings.Fields(got))
+		t.Log(got)
+		t.Fatalf("Failed:\n%s", diff)
+	}
+
+	assert.NotContains(logBuf.String(), filepath.FromSlash("/work/base/s"))
+}
+
+func TestPageBundlerCaptureNoActiveBundleHeader(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+	cfg, fs := newTestCfg()
+	cfg.Set("workingDir", "/work")
+	cfg.Set("contentDir", "base")
+	cfg.Set("defaultContentLanguage", "en")
+	cfg.Set("languages", map[string]interface{}{
+		"en": map[string]interface{}{
+			"weight": 1,
+		},
+		"nn": map[string]interface{}{
+			"weight": 2,
+		},
+	})
+	cfg.Set("disableLanguages", []string{"nn"})
+	assert.NoError(loadDefaultSettingsFor(cfg))
+	assert.NoError(loadLanguageSettings(cfg, nil))
+
+	// The only bundle header is in a disabled language.
+	writeSource(t, fs, filepath.FromSlash("/work/base/lb/index.nn.md"), "content")
+	writeSource(t, fs, filepath.FromSlash("/work/base/lb/page.md"), "content")
+	writeSource(t, fs, filepath.FromSlash("/work/base/lb/logo.png"), "image")
+	writeSource(t, fs, filepath.FromSlash("/work/base/lb/c/d.png"), "image")
+	writeSource(t, fs, filepath.FromSlash("/work/base/bb/_index.nn.md"), "content")
+	writeSource(t, fs, filepath.FromSlash("/work/base/bb/logo.png"), "image")
+
+	ps, err := helpers.NewPathSpec(fs, cfg)
+	assert.NoError(err)
+	sourceSpec := source.NewSourceSpec(ps, ps.BaseFs.Content.Fs)
+
+	logger, logBuf := newWarningBufferLogger()
+
+	fileStore := &storeFilenames{}
+	c := newCapturer(logger, sourceSpec, fileStore, nil)
+
+	assert.NoError(c.capture())
+	assert.Equal("\nF:\n\nD:\n\nC:\n\n", fileStore.sortedStr())
+	logged := logBuf.String()
+	assert.Equal(2, strings.Count(logged, "has no bundle header"))
+	assert.Contains(logged, fmt.Sprintf("Content dir %q has no bundle header in any enabled language; skipped the bundled files %q.",
+		filepath.FromSlash("/work/base/lb"), []string{filepath.FromSlash("/work/base/lb/logo.png"), filepath.FromSlash("/work/base/lb/page.md"), filepath.FromSlash("/work/base/lb/c/d.png")}))
+	assert.Contains(logged, fmt.Sprintf("Content dir %q has no bundle header", filepath.FromSlash("/work/base/bb")))
+}
+
+func TestPageBundlerCaptureSortFilenames(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+	cfg, fs := newTestCfg()
+	ps, err := helpers.NewPathSpec(fs, cfg)
+	assert.NoError(err)
+	sourceSpec := source.NewSourceSpec(ps, fs.Source)
+
+	filenames := []string{
+		filepath.FromSlash("b/logo.png"),
+		filepath.FromSlash("b/_index.md"),
+		filepath.FromSlash("a/page.md"),
+		filepath.FromSlash("b/index.md"),
+		filepath.FromSlash("a/_index.md"),
+		filepath.FromSlash("a/data.json"),
+		filepath.FromSlash("c/index.nn.md"),
+	}
+
+	expected := []string{
+		filepath.FromSlash("a/_index.md"),
+		filepath.FromSlash("b/_index.md"),
+		filepath.FromSlash("b/index.md"),
+		filepath.FromSlash("c/index.nn.md"),
+		filepath.FromSlash("a/data.json"),
+		filepath.FromSlash("a/page.md"),
+		filepath.FromSlash("b/logo.png"),
+	}
+
+	for i := 0; i < len(filenames); i++ {
+		// Rotate the input to make sure the result does not depend on the order.
+		input := append(append([]string{}, filenames[i:]...), filenames[:i]...)
+		c := newCapturer(loggers.NewErrorLogger(), sourceSpec, new(noOpFileStore), nil, input...)
+		assert.Equal(expected, c.filenames)
+	}
+}
+
+func TestPageBundlerCaptureClonedBundleResources(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+	cfg, fs := newTestCfg()
+	cfg.Set("workingDir", "/work")
+	cfg.Set("contentDir", "base")
+	cfg.Set("defaultContentLanguage", "en")
+	cfg.Set("languages", map[string]interface{}{
+		"en": map[string]interface{}{
+			"weight": 1,
+		},
+		"fr": map[string]interface{}{
+			"weight": 2,
+		},
+	})
+	assert.NoError(loadDefaultSettingsFor(cfg))
+	assert.NoError(loadLanguageSettings(cfg, nil))
+
+	writeSource(t, fs, filepath.FromSlash("/work/base/a/index.md"), "content")
+	writeSource(t, fs, filepath.FromSlash("/work/base/a/index.fr.md"), "content")
+	writeSource(t, fs, filepath.FromSlash("/work/base/a/photo.jpg"), "image")
+
+	// No French bundle header, so the English one is cloned for page.fr.md.
+	// Note that the shared files sort both before and after page.fr.md.
+	writeSource(t, fs, filepath.FromSlash("/work/base/b/index.md"), "content")
+	writeSource(t, fs, filepath.FromSlash("/work/base/b/a.jpg"), "image")
+	writeSource(t, fs, filepath.FromSlash("/work/base/b/logo.png"), "image")
+	writeSource(t, fs, filepath.FromSlash("/work/base/b/logo.fr.png"), "image")
+	writeSource(t, fs, filepath.FromSlash("/work/base/b/page.fr.md"), "content")
+	writeSource(t, fs, filepath.FromSlash("/work/base/b/photo.jpg"), "image")
+
+	ps, err := helpers.NewPathSpec(fs, cfg)
+	assert.NoError(err)
+	sourceSpec := source.NewSourceSpec(ps, ps.BaseFs.Content.Fs)
+
+	fileStore := &storeFilenames{}
+	c := newCapturer(loggers.NewErrorLogger(), sourceSpec, fileStore, nil)
+
+	assert.NoError(c.capture())
+
+	expected := `
+F:
+
+D:
+__bundle/en/work/base/a/index.md/resources/en/work/base/a/photo.jpg
+__bundle/en/work/base/b/index.md/resources/en/work/base/b/a.jpg|en/work/base/b/logo.png|en/work/base/b/photo.jpg
+__bundle/fr/work/base/a/index.fr.md/resources/en/work/base/a/photo.jpg
+__bundle/fr/work/base/b/index.md/resources/en/work/base/b/a.jpg|en/work/base/b/photo.jpg|fr/work/base/b/logo.fr.png|fr/work/base/b/page.fr.md
+C:
+
+`
+
+	got := fileStore.sortedStr()
+
+	if expected != got {
+		diff := helpers.DiffStringSlices(strings.Fields(expected), strings.Fields(got))
+		t.Log(got)
+		t.Fatalf("Failed:\n%s", diff)
+	}
+}
+
+func TestPageBundlerCaptureSymlinkedDirsDeterministic(t *testing.T) {
+	if runtime.GOOS == "windows" && os.Getenv("CI") == "" {
+		t.Skip("Skip TestPageBundlerCaptureSymlinkedDirsDeterministic as os.Symlink needs administrator rights on Windows")
+	}
+
+	assert := require.New(t)
+
+	cfg := viper.New()
+	fs := hugofs.NewFrom(hugofs.Os, cfg)
+	assert.NoError(loadDefaultSettingsFor(cfg))
+
+	workDir, clean, err := createTempDir("hugosymdeterm")
+	assert.NoError(err)
+	defer clean()
+
+	cfg.Set("workingDir", workDir)
+	cfg.Set("contentDir", "content")
+	assert.NoError(loadLanguageSettings(cfg, nil))
+
+	contentDir := filepath.Join(workDir, "content")
+	sharedDir := filepath.Join(workDir, "shared")
+	assert.NoError(fs.Source.MkdirAll(sharedDir, 0777))
+	writeSource(t, fs, filepath.Join(sharedDir, "p.md"), "content")
+
+	// Cousin dirs with symbolic links to the same dir. They are walked
+	// concurrently, but it must always be the same link that wins.
+	for _, sect := range []string{"x", "y"} {
+		assert.NoError(fs.Source.MkdirAll(filepath.Join(contentDir, sect), 0777))
+		writeSource(t, fs, filepath.Join(contentDir, sect, "page.md"), "content")
+		assert.NoError(os.Symlink(sharedDir, filepath.Join(contentDir, sect, "link")))
+	}
+	for i := 0; i < 10; i++ {
+		sect := filepath.Join(contentDir, fmt.Sprintf("sect%d", i))
+		assert.NoError(fs.Source.MkdirAll(sect, 0777))
+		writeSource(t, fs, filepath.Join(sect, "page.md"), "content")
+	}
+
+	// A leaf bundle with a symbolic link to a dir further down gets deferred
+	// after its dir listing is read. Its warnings must still only be logged once.
+	leafDir := filepath.Join(contentDir, "sect", "lb")
+	leafSharedDir := filepath.Join(workDir, "leafshared")
+	assert.NoError(fs.Source.MkdirAll(filepath.Join(leafDir, "sub"), 0777))
+	assert.NoError(fs.Source.MkdirAll(leafSharedDir, 0777))
+	writeSource(t, fs, filepath.Join(leafSharedDir, "data.json"), "{}")
+	writeSource(t, fs, filepath.Join(leafDir, "index.md"), "content")
+	writeSource(t, fs, filepath.Join(leafDir, "_index.md"), "content")
+	writeSource(t, fs, filepath.Join(leafDir, "logo.png"), "image")
+	caseSensitive := runtime.GOOS != "darwin" && runtime.GOOS != "windows"
+	if caseSensitive {
+		writeSource(t, fs, filepath.Join(leafDir, "Logo.png"), "image")
+	}
+	assert.NoError(os.Symlink(filepath.Join("..", "..", "..", "..", "leafshared"), filepath.Join(leafDir, "sub", "link")))
+
+	ps, err := helpers.NewPathSpec(fs, cfg)
+	assert.NoError(err)
+	sourceSpec := source.NewSourceSpec(ps, ps.BaseFs.Content.Fs)
+
+	for i := 0; i < 100; i++ {
+		logger, logBuf := newWarningBufferLogger()
+
+		fileStore := &storeFilenames{}
+		c := newCapturer(logger, sourceSpec, fileStore, nil)
+		// Make sure the dirs are handled concurrently, even with one CPU.
+		c.sem = make(chan bool, 8)
+
+		assert.NoError(c.capture())
+
+		got := strings.Replace(fileStore.sortedStr(), filepath.ToSlash(workDir), "", -1)
+
+		assert.Contains(got, "/content/x/link/p.md")
+		assert.NotContains(got, "/content/y/link/p.md")
+		assert.Contains(logBuf.String(), fmt.Sprintf("%q is a symbolic link to %q", filepath.Join(contentDir, "y", "link"), sharedDir))
+
+		assert.Contains(got, "__bundle/en/content/sect/lb/index.md/resources/")
+		assert.Contains(got, "en/content/sect/lb/sub/link/data.json")
+		assert.Equal(1, strings.Count(logBuf.String(), "has both a leaf bundle header"))
+		if caseSensitive {
+			assert.Equal(1, strings.Count(logBuf.String(), "only differ in case"))
+		}
+	}
+}
+
+func TestPageBundlerCaptureManyDirs(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+	cfg, fs := newTestCfg()
+	cfg.Set("workingDir", "/work")
+	cfg.Set("contentDir", "base")
+	assert.NoError(loadDefaultSettingsFor(cfg))
+	assert.NoError(loadLanguageSettings(cfg, nil))
+
+	var singles, bundles, copies []string
+
+	for i := 0; i < 20; i++ {
+		sect := fmt.Sprintf("/work/base/sect%02d", i)
+		for j := 0; j < 3; j++ {
+			single := fmt.Sprintf("%s/sub%d/p1.md", sect, j)
+			header := fmt.Sprintf("%s/sub%d/b/index.md", sect, j)
+			resource := fmt.Sprintf("%s/sub%d/b/c/logo.png", sect, j)
+			static := fmt.Sprintf("%s/sub%d/static/style.css", sect, j)
+			writeSource(t, fs, filepath.FromSlash(single), "content")
+			writeSource(t, fs, filepath.FromSlash(header), "content")
+			writeSource(t, fs, filepath.FromSlash(resource), "image")
+			writeSource(t, fs, filepath.FromSlash(static), "css")
+			singles = append(singles, single)
+			bundles = append(bundles, path.Join("__bundle/en", header, "resources/en", resource))
+			copies = append(copies, static)
+		}
+	}
+
+	sort.Strings(singles)
+	sort.Strings(bundles)
+	sort.Strings(copies)
+
+	expected := "\nF:\n" + strings.Join(singles, "\n") + "\nD:\n" + strings.Join(bundles, "\n") +
+		"\nC:\n" + strings.Join(copies, "\n") + "\n"
+
+	ps, err := helpers.NewPathSpec(fs, cfg)
+	assert.NoError(err)
+	sourceSpec := source.NewSourceSpec(ps, ps.BaseFs.Content.Fs)
+
+	// The sibling dirs are handled concurrently, so run it a few times.
+	for i := 0; i < 3; i++ {
+		fileStore := &storeFilenames{}
+		c := newCapturer(loggers.NewErrorLogger(), sourceSpec, fileStore, nil)
+
+		assert.NoError(c.capture())
+
+		got := fileStore.sortedStr()
+
+		if expected != got {
+			diff := helpers.DiffStringSlices(strings.Fields(expected), strings.Fields(got))
+			t.Log(got)
+			t.Fatalf("Failed:\n%s", diff)
+		}
+	}
+}
+
+func TestPageBundlerCaptureNameCollisions(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+
+	for _, disablePathToLower := range []bool{false, true} {
+		cfg, fs := newTestCfg()
+		cfg.Set("workingDir", "/work")
+		cfg.Set("contentDir", "base")
+		cfg.Set("disablePathToLower", disablePathToLower)
+		assert.NoError(loadDefaultSettingsFor(cfg))
+		assert.NoError(loadLanguageSettings(cfg, nil))
+
+		writeSource(t, fs, filepath.FromSlash("/work/base/About.md"), "content")
+		writeSource(t, fs, filepath.FromSlash("/work/base/about.md"), "content")
+		writeSource(t, fs, filepath.FromSlash("/work/base/b/index.md"), "content")
+		writeSource(t, fs, filepath.FromSlash("/work/base/b/Logo.png"), "image")
+		writeSource(t, fs, filepath.FromSlash("/work/base/b/logo.png"), "image")
+		writeSource(t, fs, filepath.FromSlash("/work/base/c/page.md"), "content")
+		// NFC and NFD.
+		writeSource(t, fs, filepath.FromSlash("/work/base/d/caf\u00e9.md"), "content")
+		writeSource(t, fs, filepath.FromSlash("/work/base/d/cafe\u0301.md"), "content")
+		writeSource(t, fs, filepath.FromSlash("/work/base/e/cre\u0300me.md"), "content")
+
+		ps, err := helpers.NewPathSpec(fs, cfg)
+		assert.NoError(err)
+		sourceSpec := source.NewSourceSpec(ps, ps.BaseFs.Content.Fs)
+
+		logger, logBuf := newWarningBufferLogger()
+
+		c := newCapturer(logger, sourceSpec, &storeFilenames{}, nil)
+
+		assert.NoError(c.capture())
+
+		logged := logBuf.String()
+
+		assert.Equal(1, strings.Count(logged, "only differ in Unicode normalization form"))
+		assert.Contains(logged, filepath.FromSlash("Content files \"/work/base/d/cafe\u0301.md\" and \"/work/base/d/caf\u00e9.md\" only differ in Unicode normalization form"))
+
+		assert.Equal(2, strings.Count(logged, "only differ in case"))
+		assert.Contains(logged, filepath.FromSlash(`Content files "/work/base/About.md" and "/work/base/about.md" only differ in case`))
+		assert.Contains(logged, filepath.FromSlash(`Content files "/work/base/b/Logo.png" and "/work/base/b/logo.png" only differ in case`))
+
+		if disablePathToLower {
+			assert.NotContains(logged, "published to the same path")
+		} else {
+			assert.Equal(2, strings.Count(logged, "published to the same path"))
+		}
+
+		// In server mode, handleBranchDir reads the dir before it hands it
+		// over to handleDir.
+		logger, logBuf = newWarningBufferLogger()
+		c = newCapturer(logger, sourceSpec, &storeFilenames{}, nil)
+
+		assert.NoError(c.handleBranchDir("/b"))
+		assert.Equal(1, strings.Count(logBuf.String(), "only differ in case"))
+	}
+}
+
+func TestPageBundlerCaptureSymlinkedTranslations(t *testing.T) {
+	if runtime.GOOS == "windows" && os.Getenv("CI") == "" {
+		t.Skip("Skip TestPageBundlerCaptureSymlinkedTranslations as os.Symlink needs administrator rights on Windows")
+	}
+
+	assert := require.New(t)
+
+	cfg := viper.New()
+	fs := hugofs.NewFrom(hugofs.Os, cfg)
+	assert.NoError(loadDefaultSettingsFor(cfg))
+
+	workDir, clean, err := createTempDir("hugosymlang")
+	assert.NoError(err)
+	defer clean()
+
+	cfg.Set("workingDir", workDir)
+	cfg.Set("contentDir", "content")
+	cfg.Set("defaultContentLanguage", "en")
+	cfg.Set("languages", map[string]interface{}{
+		"en": map[string]interface{}{
+			"weight": 1,
+		},
+		"de": map[string]interface{}{
+			"weight": 2,
+		},
+	})
+	assert.NoError(loadLanguageSettings(cfg, nil))
+
+	contentDir := filepath.Join(workDir, "content")
+	sharedDir := filepath.Join(workDir, "translations")
+	assert.NoError(fs.Source.MkdirAll(filepath.Join(contentDir, "lb"), 0777))
+	assert.NoError(fs.Source.MkdirAll(sharedDir, 0777))
+	writeSource(t, fs, filepath.Join(contentDir, "lb", "index.md"), "content")
+	writeSource(t, fs, filepath.Join(contentDir, "lb", "logo.png"), "image")
+	writeSource(t, fs, filepath.Join(sharedDir, "lb.md"), "content")
+	writeSource(t, fs, filepath.Join(sharedDir, "index.md"), "content")
+
+	// The language and the bundle header role come from the name of the
+	// symbolic link, not from the name of its target.
+	assert.NoError(os.Symlink(filepath.Join(sharedDir, "lb.md"), filepath.Join(contentDir, "lb", "index.de.md")))
+	assert.NoError(os.Symlink(filepath.Join(sharedDir, "index.md"), filepath.Join(contentDir, "lb", "page.de.md")))
+
+	ps, err := helpers.NewPathSpec(fs, cfg)
+	assert.NoError(err)
+	sourceSpec := source.NewSourceSpec(ps, ps.BaseFs.Content.Fs)
+
+	fileStore := &storeFilenames{}
+	c := newCapturer(loggers.NewErrorLogger(), sourceSpec, fileStore, nil)
+
+	assert.NoError(c.capture())
+
+	expected := `
+F:
+
+D:
+__bundle/de/content/lb/index.de.md/resources/de/content/lb/page.de.md|en/content/lb/logo.png
+__bundle/en/content/lb/index.md/resources/en/content/lb/logo.png
+C:
+
+`
+
+	got := strings.Replace(fileStore.sortedStr(), filepath.ToSlash(workDir), "", -1)
+
+	if expected != got {
+		diff := helpers.DiffStringSlices(strings.Fields(expected), strings.Fields(got))
+		t.Log(got)
+		t.Fatalf("Failed:\n%s", diff)
+	}
+}
+
 type noOpFileStore int
 
 func (noOpFileStore) handleSingles(fis ...*fileInfo)   {}
@@ -398,7 +398,7 @@ func TestPageBundlerSiteWitSymbolicLinksInContent(t *testing.T) {
 
 	th := testHelper{s.Cfg, s.Fs, t}
 
-	assert.Equal(7, len(s.RegularPages()))
+	assert.Equal(5, len(s.RegularPages()))
 	a1Bundle := s.getPage(page.KindPage, "symbolic2/a1/index.md")
 	assert.NotNil(a1Bundle)
 	assert.Equal(2, len(a1Bundle.Resources()))
@@ -898,7 +898,7 @@ TheContent.
 
 	os.Chdir(filepath.FromSlash("../../symcontent3"))
 
-	// Create a circular symlink. Will print some warnings.
+	// Create a circular symlink back to the content root. Will print some warnings.
 	assert.NoError(os.Symlink(filepath.Join("..", contentDir), filepath.FromSlash("circus")))
 
 	os.Chdir(workDir)
@@ -971,3 +971,41 @@ slug: %s
 	assert.True(b.CheckExists("public/about/services2/this-is-another-slug/index.html"))
 
 }
+
+func TestPageBundlerNormalizeFilenames(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+
+	for _, normalize := range []bool{false, true} {
+		b := newTestSitesBuilder(t)
+
+		b.WithConfigFile("toml", fmt.Sprintf(`
+baseURL = "https://example.org"
+normalizeFilenames = %t
+`, normalize))
+
+		// The dir and the resource are in NFD, as synced from macOS.
+		b.WithContent("cafe\u0301/index.md", `---
+title: Cafe
+---
+`, "cafe\u0301/cre\u0300me.txt", "Creme")
+
+		// The lookup is in NFC.
+		b.WithTemplatesAdded(
+			"index.html", "{{ with .Site.GetPage \"caf\u00e9\" }}Found: {{ .RelPermalink }}{{ else }}Not found{{ end }}",
+			"_default/single.html", "{{ range .Resources }}{{ .RelPermalink }}|{{ .Content }}{{ end }}")
+
+		b.CreateSites().Build(BuildCfg{})
+
+		if !normalize {
+			b.AssertFileContent("public/index.html", "Not found")
+			b.AssertFileContent("public/cafe\u0301/index.html", "/cafe%CC%81/cre%CC%80me.txt|Creme")
+			continue
+		}
+
+		b.AssertFileContent("public/index.html", "Found: /caf%C3%A9/")
+		b.AssertFileContent("public/caf\u00e9/index.html", "/caf%C3%A9/cr%C3%A8me.txt|Creme")
+		assert.True(b.CheckExists("public/caf\u00e9/cr\u00e8me.txt"))
+	}
+}
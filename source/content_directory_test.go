@@ -48,6 +48,8 @@ func TestIgnoreDotFilesAndDirectories(t *testing.T) {
 		{"foobar/foo.md", true, []string{"*", "\\.md$", "\\.boo$"}},
 		{"foobar/.#content.md", true, []string{"/\\.#"}},
 		{".#foobar.md", true, []string{"^\\.#"}},
+		{"foobar/_drafts", true, []string{"/_drafts$"}},
+		{"foobar/_index.md", false, []string{"/_drafts$"}},
 	}
 
 	for i, test := range tests {
@@ -24,6 +24,7 @@ import (
 	"github.com/gohugoio/hugo/hugofs"
 
 	"github.com/gohugoio/hugo/helpers"
+	"golang.org/x/text/unicode/norm"
 )
 
 // fileInfo implements the File interface.
@@ -270,6 +271,16 @@ func (sp *SourceSpec) NewFileInfo(baseDir, filename string, isLeafBundle bool, f
 		translationBaseName = strings.TrimSuffix(baseName, fileLangExt)
 	}
 
+	if sp.normalizeFilenames {
+		// Only the logical names are normalized. The filename is used to open
+		// the file, so it must match the name on disk.
+		relDir = norm.NFC.String(relDir)
+		relPath = norm.NFC.String(relPath)
+		name = norm.NFC.String(name)
+		baseName = norm.NFC.String(baseName)
+		translationBaseName = norm.NFC.String(translationBaseName)
+	}
+
 	f := &FileInfo{
 		sp:                  sp,
 		filename:            filename,
@@ -108,3 +108,37 @@ func TestFileInfoLanguage(t *testing.T) {
 	fi = s.NewFileInfo("", filepath.Join("2018-10-01-contentbasename", "_index.en.md"), false, nil)
 	assert.Equal("_index", fi.ContentBaseName())
 }
+
+func TestFileInfoNormalizeFilenames(t *testing.T) {
+	assert := require.New(t)
+
+	// NFD, as synced from macOS.
+	filename := filepath.FromSlash("/a/cafe\u0301/cre\u0300me.fr.md")
+
+	for _, normalize := range []bool{false, true} {
+		v := newTestConfig()
+		v.Set("normalizeFilenames", normalize)
+		fs := hugofs.NewMem(v)
+		ps, err := helpers.NewPathSpec(fs, v)
+		assert.NoError(err)
+		s := NewSourceSpec(ps, fs.Source)
+
+		f := s.NewFileInfo(filepath.FromSlash("/a/"), filename, false, nil)
+
+		// The filename must match the name on disk.
+		assert.Equal(filename, f.Filename())
+
+		if !normalize {
+			assert.Equal(filepath.FromSlash("cafe\u0301/cre\u0300me.fr.md"), f.Path())
+			assert.Equal("cafe\u0301", f.Section())
+			continue
+		}
+
+		assert.Equal(filepath.FromSlash("caf\u00e9/"), f.Dir())
+		assert.Equal(filepath.FromSlash("caf\u00e9/cr\u00e8me.fr.md"), f.Path())
+		assert.Equal("caf\u00e9", f.Section())
+		assert.Equal("cr\u00e8me.fr.md", f.LogicalName())
+		assert.Equal("cr\u00e8me.fr", f.BaseFileName())
+		assert.Equal("cr\u00e8me", f.TranslationBaseName())
+	}
+}
@@ -35,6 +35,9 @@ type SourceSpec struct {
 	// This is set if the ignoreFiles config is set.
 	ignoreFilesRe []*regexp.Regexp
 
+	// This is set if the normalizeFilenames config is set.
+	normalizeFilenames bool
+
 	Languages              map[string]interface{}
 	DefaultContentLanguage string
 	DisabledLanguages      map[string]bool
@@ -72,7 +75,7 @@ func NewSourceSpec(ps *helpers.PathSpec, fs afero.Fs) *SourceSpec {
 		}
 	}
 
-	return &SourceSpec{ignoreFilesRe: regexps, PathSpec: ps, SourceFs: fs, Languages: languages, DefaultContentLanguage: defaultLang, DisabledLanguages: disabledLangsSet}
+	return &SourceSpec{ignoreFilesRe: regexps, normalizeFilenames: cfg.GetBool("normalizeFilenames"), PathSpec: ps, SourceFs: fs, Languages: languages, DefaultContentLanguage: defaultLang, DisabledLanguages: disabledLangsSet}
 
 }
 